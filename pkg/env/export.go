@@ -0,0 +1,151 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// TODO: no binary in this tree calls MarshalJSON/MarshalYAML/MarshalMarkdown
+// yet. The intent is a hidden --dump-env-schema=json|yaml|markdown
+// subcommand in pilot-discovery, mixer, and galley so istioctl and the docs
+// pipeline can consume one manifest of every ISTIO_* knob instead of
+// grep-scraping source, but none of those command trees exist in this
+// checkout to wire it into. This package's half of the contract is done;
+// the CLI wiring is not.
+
+// schemaVar is the stable, serializable view of a registered Var emitted by
+// MarshalJSON, MarshalYAML, and MarshalMarkdown.
+type schemaVar struct {
+	Name        string   `json:"name" yaml:"name"`
+	Type        string   `json:"type" yaml:"type"`
+	Default     string   `json:"default,omitempty" yaml:"default,omitempty"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Deprecated  bool     `json:"deprecated,omitempty" yaml:"deprecated,omitempty"`
+	Hidden      bool     `json:"hidden,omitempty" yaml:"hidden,omitempty"`
+	Required    bool     `json:"required,omitempty" yaml:"required,omitempty"`
+	Choices     []string `json:"choices,omitempty" yaml:"choices,omitempty"`
+	Min         *float64 `json:"min,omitempty" yaml:"min,omitempty"`
+	Max         *float64 `json:"max,omitempty" yaml:"max,omitempty"`
+	Pattern     string   `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Sensitive   bool     `json:"sensitive,omitempty" yaml:"sensitive,omitempty"`
+}
+
+func toSchema(vars []Var) []schemaVar {
+	out := make([]schemaVar, 0, len(vars))
+	for _, v := range vars {
+		out = append(out, schemaVar{
+			Name:        v.Name,
+			Type:        v.Type.String(),
+			Default:     v.DefaultValue,
+			Description: v.Description,
+			Deprecated:  v.Deprecated,
+			Hidden:      v.Hidden,
+			Required:    v.Required,
+			Choices:     v.Choices,
+			Min:         v.Min,
+			Max:         v.Max,
+			Pattern:     v.Pattern,
+			Sensitive:   v.Sensitive,
+		})
+	}
+	return out
+}
+
+// MarshalJSON renders every registered Var, as returned by VarDescriptions,
+// as a stable JSON schema suitable for consumption by istioctl or the docs
+// pipeline.
+func MarshalJSON() ([]byte, error) {
+	return json.MarshalIndent(toSchema(VarDescriptions()), "", "  ")
+}
+
+// MarshalYAML renders every registered Var as a stable YAML schema. See
+// MarshalJSON.
+func MarshalYAML() ([]byte, error) {
+	return yaml.Marshal(toSchema(VarDescriptions()))
+}
+
+// MarshalMarkdown renders every registered Var as a Markdown table, for
+// embedding in generated operator documentation.
+func MarshalMarkdown() []byte {
+	var b bytes.Buffer
+	b.WriteString("| Name | Type | Default | Required | Description |\n")
+	b.WriteString("| ---- | ---- | ------- | -------- | ----------- |\n")
+
+	for _, v := range toSchema(VarDescriptions()) {
+		desc := v.Description
+		if len(v.Choices) > 0 {
+			desc = fmt.Sprintf("%s (one of: %v)", desc, v.Choices)
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | `%s` | %v | %s |\n", v.Name, v.Type, v.Default, v.Required, desc)
+	}
+
+	return b.Bytes()
+}
+
+// DiffResult reports how two snapshots of registered Vars differ.
+type DiffResult struct {
+	// Added lists variables present in new but not old.
+	Added []string
+
+	// Removed lists variables present in old but not new.
+	Removed []string
+
+	// Deprecated lists variables present in both that became deprecated in
+	// new.
+	Deprecated []string
+}
+
+// Diff compares two snapshots of registered Vars, typically VarDescriptions
+// captured against two different Istio versions, so release tooling can
+// flag added, removed, or newly deprecated ISTIO_* variables.
+func Diff(old, new []Var) DiffResult {
+	oldByName := make(map[string]Var, len(old))
+	for _, v := range old {
+		oldByName[v.Name] = v
+	}
+	newByName := make(map[string]Var, len(new))
+	for _, v := range new {
+		newByName[v.Name] = v
+	}
+
+	var d DiffResult
+	for name, nv := range newByName {
+		ov, ok := oldByName[name]
+		if !ok {
+			d.Added = append(d.Added, name)
+			continue
+		}
+		if !ov.Deprecated && nv.Deprecated {
+			d.Deprecated = append(d.Deprecated, name)
+		}
+	}
+	for name := range oldByName {
+		if _, ok := newByName[name]; !ok {
+			d.Removed = append(d.Removed, name)
+		}
+	}
+
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Deprecated)
+
+	return d
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToSchema(t *testing.T) {
+	min := 1.0
+	max := 10.0
+
+	vars := []Var{
+		{Name: "B", DefaultValue: "b-default", Description: "b desc", Type: STRING, Choices: []string{"b-default", "x"}},
+		{Name: "A", DefaultValue: "5", Type: INT, Min: &min, Max: &max, Required: true},
+	}
+
+	got := toSchema(vars)
+	if len(got) != 2 {
+		t.Fatalf("toSchema() returned %d entries, want 2", len(got))
+	}
+
+	want := []schemaVar{
+		{Name: "B", Type: "string", Default: "b-default", Description: "b desc", Choices: []string{"b-default", "x"}},
+		{Name: "A", Type: "int", Default: "5", Required: true, Min: &min, Max: &max},
+	}
+
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("toSchema()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDiffAddedRemovedDeprecated(t *testing.T) {
+	old := []Var{
+		{Name: "KEPT"},
+		{Name: "REMOVED"},
+		{Name: "NEWLY_DEPRECATED"},
+		{Name: "ALREADY_DEPRECATED", Deprecated: true},
+	}
+	new := []Var{ // nolint: predeclared
+		{Name: "KEPT"},
+		{Name: "NEWLY_DEPRECATED", Deprecated: true},
+		{Name: "ALREADY_DEPRECATED", Deprecated: true},
+		{Name: "ADDED"},
+	}
+
+	d := Diff(old, new)
+
+	if !reflect.DeepEqual(d.Added, []string{"ADDED"}) {
+		t.Errorf("Added = %v, want [ADDED]", d.Added)
+	}
+	if !reflect.DeepEqual(d.Removed, []string{"REMOVED"}) {
+		t.Errorf("Removed = %v, want [REMOVED]", d.Removed)
+	}
+	if !reflect.DeepEqual(d.Deprecated, []string{"NEWLY_DEPRECATED"}) {
+		t.Errorf("Deprecated = %v, want [NEWLY_DEPRECATED]", d.Deprecated)
+	}
+}
+
+func TestDiffNoPriorSnapshot(t *testing.T) {
+	new := []Var{{Name: "A"}, {Name: "B"}} // nolint: predeclared
+
+	d := Diff(nil, new)
+
+	if !reflect.DeepEqual(d.Added, []string{"A", "B"}) {
+		t.Errorf("Added = %v, want [A B]", d.Added)
+	}
+	if len(d.Removed) != 0 {
+		t.Errorf("Removed = %v, want none", d.Removed)
+	}
+	if len(d.Deprecated) != 0 {
+		t.Errorf("Deprecated = %v, want none", d.Deprecated)
+	}
+}
+
+func TestDiffEmptyNewSnapshot(t *testing.T) {
+	old := []Var{{Name: "A"}, {Name: "B"}}
+
+	d := Diff(old, nil)
+
+	if len(d.Added) != 0 {
+		t.Errorf("Added = %v, want none", d.Added)
+	}
+	if !reflect.DeepEqual(d.Removed, []string{"A", "B"}) {
+		t.Errorf("Removed = %v, want [A B]", d.Removed)
+	}
+}
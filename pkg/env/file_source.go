@@ -0,0 +1,152 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileSource is a Source backed by a directory of key files, following the
+// standard Kubernetes ConfigMap/Secret volume projection convention: each
+// file's basename is the variable name, and its (trimmed) contents are the
+// value. FileSource polls the directory on an interval, since the usual
+// atomic-symlink-swap update performed by the kubelet doesn't generate a
+// filesystem event that's portable to watch for.
+type FileSource struct {
+	dir      string
+	interval time.Duration
+
+	mu       sync.Mutex
+	values   map[string]string
+	watchers map[string][]func(string)
+	done     chan struct{}
+}
+
+// NewFileSource creates a FileSource that polls dir every interval. Call Run
+// to start polling; typical usage is `env.SetSource(s); go s.Run()`.
+func NewFileSource(dir string, interval time.Duration) *FileSource {
+	return &FileSource{
+		dir:      dir,
+		interval: interval,
+		values:   make(map[string]string),
+		watchers: make(map[string][]func(string)),
+		done:     make(chan struct{}),
+	}
+}
+
+// Lookup reads name's current value directly from its key file.
+func (f *FileSource) Lookup(name string) (string, bool) {
+	v, err := readKeyFile(filepath.Join(f.dir, name))
+	if err != nil {
+		return "", false
+	}
+	return v, true
+}
+
+// Watch registers cb to be called on every poll tick started by Run. When
+// name's literal key file exists in this directory, cb only fires if its
+// contents differ from what they were at registration time (the first poll
+// after Watch is called, not unconditionally). When the key file doesn't
+// exist -- because the value is supplied via <name>_FILE indirection
+// instead, which this Source can't see -- cb fires on every tick and the
+// caller is expected to do its own deduplication (as dispatchChange does via
+// resolveRaw).
+func (f *FileSource) Watch(name string, cb func(newVal string)) {
+	f.mu.Lock()
+	if _, seeded := f.values[name]; !seeded {
+		if v, err := readKeyFile(filepath.Join(f.dir, name)); err == nil {
+			f.values[name] = v
+		}
+	}
+	f.watchers[name] = append(f.watchers[name], cb)
+	f.mu.Unlock()
+}
+
+// Run polls the directory for changes until Stop is called. It blocks, so
+// callers typically invoke it in its own goroutine.
+func (f *FileSource) Run() {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			f.poll()
+		case <-f.done:
+			return
+		}
+	}
+}
+
+// Stop terminates the polling loop started by Run.
+func (f *FileSource) Stop() {
+	close(f.done)
+}
+
+func (f *FileSource) poll() {
+	f.mu.Lock()
+	names := make([]string, 0, len(f.watchers))
+	for name := range f.watchers {
+		names = append(names, name)
+	}
+	f.mu.Unlock()
+
+	for _, name := range names {
+		v, err := readKeyFile(filepath.Join(f.dir, name))
+		if err != nil {
+			// No literal <name> key file in this directory -- the value may
+			// still be supplied via <name>_FILE indirection elsewhere, which
+			// this Source has no visibility into. Fire unconditionally so
+			// dispatchChange's own resolveRaw-based resolution (which does
+			// understand _FILE indirection) gets a chance to notice a
+			// change instead of this Watch going permanently silent.
+			f.mu.Lock()
+			cbs := append([]func(string){}, f.watchers[name]...)
+			f.mu.Unlock()
+
+			for _, cb := range cbs {
+				cb("")
+			}
+			continue
+		}
+
+		f.mu.Lock()
+		old, seen := f.values[name]
+		changed := !seen || old != v
+		if changed {
+			f.values[name] = v
+		}
+		cbs := append([]func(string){}, f.watchers[name]...)
+		f.mu.Unlock()
+
+		if changed {
+			for _, cb := range cbs {
+				cb(v)
+			}
+		}
+	}
+}
+
+func readKeyFile(path string) (string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\n"), nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadKeyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "env_test")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		name    string
+		content []byte
+		write   bool
+		want    string
+		wantErr bool
+	}{
+		{name: "missing", write: false, wantErr: true},
+		{name: "empty", content: []byte(""), write: true, want: ""},
+		{name: "trailing-newline", content: []byte("s3cr3t\n"), write: true, want: "s3cr3t"},
+		{name: "no-trailing-newline", content: []byte("s3cr3t"), write: true, want: "s3cr3t"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(dir, c.name)
+			if c.write {
+				if err := ioutil.WriteFile(path, c.content, 0600); err != nil {
+					t.Fatalf("WriteFile failed: %v", err)
+				}
+			}
+
+			got, err := readKeyFile(path)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("readKeyFile(%s): expected an error, got none", path)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("readKeyFile(%s) failed: %v", path, err)
+			}
+			if got != c.want {
+				t.Fatalf("readKeyFile(%s) = %q, want %q", path, got, c.want)
+			}
+		})
+	}
+}
+
+// fakeSource is a minimal Source backed by a map, used to control exactly
+// which names are "set" without touching the real process environment.
+type fakeSource map[string]string
+
+func (f fakeSource) Lookup(name string) (string, bool) {
+	v, ok := f[name]
+	return v, ok
+}
+
+func (f fakeSource) Watch(name string, cb func(newVal string)) {}
+
+func TestLookupResolvedFileIndirection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "env_test")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	secretPath := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(secretPath, []byte("from-file\n"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	prev := currentSource()
+	defer SetSource(prev)
+
+	t.Run("precedence: FOO_FILE wins over FOO", func(t *testing.T) {
+		SetSource(fakeSource{"FOO": "from-env", "FOO_FILE": secretPath})
+
+		v := Var{Name: "FOO", DefaultValue: "default"}
+		raw, explicit := lookupResolved(v)
+		if !explicit || raw != "from-file" {
+			t.Fatalf("lookupResolved() = (%q, %v), want (\"from-file\", true)", raw, explicit)
+		}
+	})
+
+	t.Run("missing FOO_FILE target falls back to FOO", func(t *testing.T) {
+		SetSource(fakeSource{"FOO": "from-env", "FOO_FILE": filepath.Join(dir, "does-not-exist")})
+
+		v := Var{Name: "FOO", DefaultValue: "default"}
+		raw, explicit := lookupResolved(v)
+		if !explicit || raw != "from-env" {
+			t.Fatalf("lookupResolved() = (%q, %v), want (\"from-env\", true)", raw, explicit)
+		}
+	})
+
+	t.Run("neither FOO nor FOO_FILE set is reported as unset", func(t *testing.T) {
+		SetSource(fakeSource{})
+
+		v := Var{Name: "FOO", DefaultValue: "default"}
+		raw, explicit := lookupResolved(v)
+		if explicit || raw != "" {
+			t.Fatalf("lookupResolved() = (%q, %v), want (\"\", false)", raw, explicit)
+		}
+	})
+}
@@ -0,0 +1,167 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"os"
+	"sync"
+)
+
+// Source abstracts where registered Vars get their values from. The default
+// Source resolves values from the process environment via os.LookupEnv, but
+// callers can install an alternative (e.g. a Source backed by a mounted
+// ConfigMap/Secret) with SetSource to pick up operator-driven configuration
+// changes without restarting the process.
+type Source interface {
+	// Lookup returns the current value of the named variable, and whether
+	// it is currently set.
+	Lookup(name string) (string, bool)
+
+	// Watch registers cb to be invoked with the new value whenever the
+	// named variable's value changes. Sources that cannot detect changes
+	// (such as the process environment) may treat this as a no-op. A Source
+	// may also invoke cb when the value has not changed (FileSource does so
+	// on every poll for a name with no key file); dispatchChange
+	// deduplicates such calls.
+	Watch(name string, cb func(newVal string))
+}
+
+var (
+	sourceMu sync.Mutex
+	source   Source = osSource{}
+)
+
+// SetSource replaces the Source used to resolve every registered Var, and
+// re-arms every outstanding OnChange subscription against it. This package's
+// own idiom is package-scope `var Foo = env.RegisterStringVar(...)`
+// (init-time registration), which means OnChange is commonly called before
+// main has a chance to install a real Source; without re-arming here, those
+// subscriptions would stay bound to the no-op default osSource forever.
+func SetSource(s Source) {
+	sourceMu.Lock()
+	source = s
+	sourceMu.Unlock()
+
+	rearmWatches()
+}
+
+func currentSource() Source {
+	sourceMu.Lock()
+	defer sourceMu.Unlock()
+	return source
+}
+
+// osSource is the default Source, backed by the process environment. This
+// preserves the historical behavior of this package.
+type osSource struct{}
+
+func (osSource) Lookup(name string) (string, bool) {
+	return os.LookupEnv(name)
+}
+
+// Watch is a no-op: the process environment cannot change after the process
+// starts, so there is nothing to observe.
+func (osSource) Watch(name string, cb func(newVal string)) {}
+
+// changeRegistration tracks the callbacks registered for one Var's OnChange,
+// plus the last value they were invoked with. Keeping v lets a bare "this
+// name changed" notification from Source.Watch be turned into a resolved,
+// validated, FILE-indirection-aware value via resolveRaw, exactly the path
+// Lookup itself uses.
+type changeRegistration struct {
+	v    Var
+	last string
+	seen bool
+	cbs  []func(string)
+}
+
+var (
+	onChangeMu sync.Mutex
+	onChange   = make(map[string]*changeRegistration)
+)
+
+// registerOnChange wires cb to fire whenever v's resolved value changes, as
+// reported by the currently installed Source. The Source is watched at most
+// once per name, regardless of how many callbacks are registered.
+func registerOnChange(v Var, cb func(string)) {
+	onChangeMu.Lock()
+	reg, exists := onChange[v.Name]
+	if !exists {
+		// Seed with the value in effect now, so the first notification from
+		// the Source fires callbacks only if the resolved value differs.
+		raw, _ := resolveRaw(v)
+		reg = &changeRegistration{v: v, last: raw, seen: true}
+		onChange[v.Name] = reg
+	}
+	reg.cbs = append(reg.cbs, cb)
+	onChangeMu.Unlock()
+
+	if !exists {
+		armWatch(v.Name)
+	}
+}
+
+// armWatch installs a Watch for name on the currently active Source. It is
+// called once per name at registration time, and again for every
+// outstanding name whenever SetSource installs a new Source.
+func armWatch(name string) {
+	currentSource().Watch(name, func(string) {
+		dispatchChange(name)
+	})
+}
+
+// rearmWatches re-installs a Watch, on the now-current Source, for every Var
+// with an outstanding OnChange subscription.
+func rearmWatches() {
+	onChangeMu.Lock()
+	names := make([]string, 0, len(onChange))
+	for name := range onChange {
+		names = append(names, name)
+	}
+	onChangeMu.Unlock()
+
+	for _, name := range names {
+		armWatch(name)
+	}
+}
+
+// dispatchChange re-resolves name's Var through resolveRaw -- the same
+// validation and NAME_FILE-indirection path Lookup uses -- and invokes its
+// registered callbacks only if the resolved value actually changed.
+func dispatchChange(name string) {
+	onChangeMu.Lock()
+	reg, ok := onChange[name]
+	onChangeMu.Unlock()
+	if !ok {
+		return
+	}
+
+	raw, _ := resolveRaw(reg.v)
+
+	onChangeMu.Lock()
+	changed := !reg.seen || reg.last != raw
+	reg.seen = true
+	reg.last = raw
+	cbs := append([]func(string){}, reg.cbs...)
+	onChangeMu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	for _, cb := range cbs {
+		cb(raw)
+	}
+}
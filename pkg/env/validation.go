@@ -0,0 +1,264 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"istio.io/istio/pkg/log"
+)
+
+// Options describes optional constraints to attach to a registered Var.
+// Which fields apply depends on the variable's type: Pattern only applies
+// to string variables, and Min/Max only apply to int, float, and duration
+// variables (compared against the duration's nanosecond count).
+type Options struct {
+	// Required marks the variable as one ValidateAll rejects if it is
+	// neither set in the environment nor given a non-empty default, or if it
+	// is set to an empty value.
+	Required bool
+
+	// Choices, if non-empty, is the set of values the variable is allowed
+	// to take.
+	Choices []string
+
+	// Min is the optional inclusive lower bound for numeric variables.
+	Min *float64
+
+	// Max is the optional inclusive upper bound for numeric variables.
+	Max *float64
+
+	// Pattern is an optional regular expression that string variables must
+	// match.
+	Pattern string
+}
+
+// RegisterStringVarWithOptions registers a new string environment variable
+// with validation constraints. It returns an error if the constraints
+// themselves are invalid (e.g. a malformed Pattern) or the default value
+// violates them.
+func RegisterStringVarWithOptions(name, defaultValue, description string, opts Options) (StringVar, error) {
+	v := Var{
+		Name: name, DefaultValue: defaultValue, Description: description, Type: STRING,
+		Required: opts.Required, Choices: opts.Choices, Pattern: opts.Pattern,
+	}
+	if err := registerWithOptions(v); err != nil {
+		return StringVar{}, err
+	}
+	return StringVar{v}, nil
+}
+
+// RegisterIntVarWithOptions registers a new integer environment variable
+// with validation constraints. See RegisterStringVarWithOptions.
+func RegisterIntVarWithOptions(name string, defaultValue int, description string, opts Options) (IntVar, error) {
+	v := Var{
+		Name: name, DefaultValue: strconv.FormatInt(int64(defaultValue), 10), Description: description, Type: INT,
+		Required: opts.Required, Choices: opts.Choices, Min: opts.Min, Max: opts.Max,
+	}
+	if err := registerWithOptions(v); err != nil {
+		return IntVar{}, err
+	}
+	return IntVar{v}, nil
+}
+
+// RegisterFloatVarWithOptions registers a new floating-point environment
+// variable with validation constraints. See RegisterStringVarWithOptions.
+func RegisterFloatVarWithOptions(name string, defaultValue float64, description string, opts Options) (FloatVar, error) {
+	v := Var{
+		Name: name, DefaultValue: strconv.FormatFloat(defaultValue, 'G', -1, 64), Description: description, Type: FLOAT,
+		Required: opts.Required, Choices: opts.Choices, Min: opts.Min, Max: opts.Max,
+	}
+	if err := registerWithOptions(v); err != nil {
+		return FloatVar{}, err
+	}
+	return FloatVar{v}, nil
+}
+
+// RegisterDurationVarWithOptions registers a new duration environment
+// variable with validation constraints. See RegisterStringVarWithOptions.
+func RegisterDurationVarWithOptions(name string, defaultValue time.Duration, description string, opts Options) (DurationVar, error) {
+	v := Var{
+		Name: name, DefaultValue: defaultValue.String(), Description: description, Type: DURATION,
+		Required: opts.Required, Choices: opts.Choices, Min: opts.Min, Max: opts.Max,
+	}
+	if err := registerWithOptions(v); err != nil {
+		return DurationVar{}, err
+	}
+	return DurationVar{v}, nil
+}
+
+func registerWithOptions(v Var) error {
+	if err := validateConstraints(v); err != nil {
+		return err
+	}
+	if v.DefaultValue != "" {
+		if err := checkValue(v, v.DefaultValue); err != nil {
+			return fmt.Errorf("default value for %s: %v", v.Name, err)
+		}
+	}
+	RegisterVar(v)
+	return nil
+}
+
+// validateConstraints sanity-checks that v's constraints make sense for its
+// type, independent of any particular value.
+func validateConstraints(v Var) error {
+	if v.Pattern != "" && v.Type != STRING {
+		return fmt.Errorf("%s: Pattern is only supported for string variables", v.Name)
+	}
+
+	if (v.Min != nil || v.Max != nil) && (v.Type == STRING || v.Type == BOOL) {
+		return fmt.Errorf("%s: Min/Max are not supported for type %v", v.Name, v.Type)
+	}
+
+	if v.Min != nil && v.Max != nil && *v.Min > *v.Max {
+		return fmt.Errorf("%s: Min (%v) is greater than Max (%v)", v.Name, *v.Min, *v.Max)
+	}
+
+	if v.Pattern != "" {
+		if _, err := regexp.Compile(v.Pattern); err != nil {
+			return fmt.Errorf("%s: invalid Pattern %q: %v", v.Name, v.Pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// checkValue validates raw, the resolved-but-not-yet-type-parsed value for
+// v, against v's constraints.
+func checkValue(v Var, raw string) error {
+	if len(v.Choices) > 0 {
+		found := false
+		for _, c := range v.Choices {
+			if c == raw {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("value %q is not one of the allowed choices %v", raw, v.Choices)
+		}
+	}
+
+	if v.Pattern != "" {
+		// validateConstraints already confirmed this compiles.
+		re := regexp.MustCompile(v.Pattern)
+		if !re.MatchString(raw) {
+			return fmt.Errorf("value %q does not match pattern %q", raw, v.Pattern)
+		}
+	}
+
+	if v.Min != nil || v.Max != nil {
+		f, err := numericValue(v, raw)
+		if err != nil {
+			return fmt.Errorf("value %q is not a valid %v: %v", raw, v.Type, err)
+		}
+		if v.Min != nil && f < *v.Min {
+			return fmt.Errorf("value %v is less than the minimum %v", f, *v.Min)
+		}
+		if v.Max != nil && f > *v.Max {
+			return fmt.Errorf("value %v is greater than the maximum %v", f, *v.Max)
+		}
+	}
+
+	return nil
+}
+
+func numericValue(v Var, raw string) (float64, error) {
+	switch v.Type {
+	case INT:
+		i, err := strconv.Atoi(raw)
+		return float64(i), err
+	case FLOAT:
+		return strconv.ParseFloat(raw, 64)
+	case DURATION:
+		d, err := time.ParseDuration(raw)
+		return float64(d), err
+	default:
+		return 0, fmt.Errorf("Min/Max constraints are not supported for type %v", v.Type)
+	}
+}
+
+// lookupResolved returns v's current raw value, honoring the standard
+// Docker/Kubernetes secret-injection convention: if <NAME>_FILE is set, its
+// (trimmed) file contents take precedence over v.Name itself. This lets
+// Sensitive variables be supplied via a mounted secret file instead of the
+// process environment. It performs no validation; callers decide what to do
+// with the result.
+func lookupResolved(v Var) (raw string, explicit bool) {
+	if path, ok := currentSource().Lookup(v.Name + "_FILE"); ok {
+		if content, err := readKeyFile(path); err != nil {
+			log.Warnf("%s_FILE=%s could not be read (%v); falling back to %s", v.Name, path, err, v.Name)
+		} else {
+			return content, true
+		}
+	}
+
+	return currentSource().Lookup(v.Name)
+}
+
+// resolveRaw resolves v's current value via lookupResolved and validates it
+// against v's constraints. A set value that fails validation is logged with
+// a distinct warning, rather than silently falling back to the default the
+// way a type-parse failure does, and is treated as unset.
+func resolveRaw(v Var) (string, bool) {
+	raw, ok := lookupResolved(v)
+	if !ok {
+		return v.DefaultValue, false
+	}
+
+	if err := checkValue(v, raw); err != nil {
+		log.Warnf("Environment variable %s failed validation (%v), using default %v", v.Name, err, v.DefaultValue)
+		return v.DefaultValue, false
+	}
+
+	return raw, true
+}
+
+// ValidateAll validates every currently registered Var against its
+// constraints, returning one error per violation. A binary such as
+// pilot-discovery can call this early in main to fail fast on a
+// misconfigured ISTIO_* variable instead of silently reverting to its
+// default; no binary in this tree calls it. It
+// resolves each Var the same way Lookup does, so a value supplied via
+// NAME_FILE indirection is honored by both the Required check and the
+// constraint checks below.
+func ValidateAll() []error {
+	var errs []error
+
+	for _, v := range VarDescriptions() {
+		raw, ok := lookupResolved(v)
+		if !ok {
+			if v.Required && v.DefaultValue == "" {
+				errs = append(errs, fmt.Errorf("%s: required but not set and has no default", v.Name))
+			}
+			continue
+		}
+
+		if v.Required && raw == "" {
+			errs = append(errs, fmt.Errorf("%s: required but set to an empty value", v.Name))
+			continue
+		}
+
+		if err := checkValue(v, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", v.Name, err))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package env
+
+import (
+	"strings"
+	"testing"
+)
+
+func floatPtr(f float64) *float64 { return &f }
+
+func TestRegisterWithOptionsRejectsBadConstraints(t *testing.T) {
+	if _, err := RegisterStringVarWithOptions("TEST_VALIDATION_BAD_PATTERN", "anything", "", Options{
+		Pattern: "(",
+	}); err == nil {
+		t.Fatal("RegisterStringVarWithOptions with a malformed Pattern: expected an error, got none")
+	}
+
+	if _, err := RegisterIntVarWithOptions("TEST_VALIDATION_MIN_GT_MAX", 5, "", Options{
+		Min: floatPtr(10), Max: floatPtr(1),
+	}); err == nil {
+		t.Fatal("RegisterIntVarWithOptions with Min > Max: expected an error, got none")
+	}
+}
+
+func TestRegisterWithOptionsRejectsBadDefault(t *testing.T) {
+	if _, err := RegisterIntVarWithOptions("TEST_VALIDATION_DEFAULT_OVER_MAX", 50, "", Options{
+		Max: floatPtr(10),
+	}); err == nil {
+		t.Fatal("RegisterIntVarWithOptions with a default above Max: expected an error, got none")
+	}
+
+	if _, err := RegisterStringVarWithOptions("TEST_VALIDATION_DEFAULT_NOT_A_CHOICE", "nope", "", Options{
+		Choices: []string{"a", "b"},
+	}); err == nil {
+		t.Fatal("RegisterStringVarWithOptions with a default outside Choices: expected an error, got none")
+	}
+
+	if _, err := RegisterStringVarWithOptions("TEST_VALIDATION_DEFAULT_OK", "a", "", Options{
+		Choices: []string{"a", "b"},
+	}); err != nil {
+		t.Fatalf("RegisterStringVarWithOptions with a valid default: unexpected error: %v", err)
+	}
+}
+
+// errsFor filters a ValidateAll result down to errors mentioning name, so
+// tests aren't tripped up by unrelated Vars registered elsewhere in the
+// package's global registry.
+func errsFor(errs []error, name string) []error {
+	var out []error
+	for _, e := range errs {
+		if strings.Contains(e.Error(), name) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func TestValidateAllRequired(t *testing.T) {
+	prev := currentSource()
+	defer SetSource(prev)
+
+	const (
+		noDefault   = "TEST_VALIDATION_REQUIRED_NO_DEFAULT"
+		withDefault = "TEST_VALIDATION_REQUIRED_WITH_DEFAULT"
+		empty       = "TEST_VALIDATION_REQUIRED_EMPTY"
+	)
+
+	if _, err := RegisterStringVarWithOptions(noDefault, "", "", Options{Required: true}); err != nil {
+		t.Fatalf("RegisterStringVarWithOptions(%s): %v", noDefault, err)
+	}
+	if _, err := RegisterStringVarWithOptions(withDefault, "a-default", "", Options{Required: true}); err != nil {
+		t.Fatalf("RegisterStringVarWithOptions(%s): %v", withDefault, err)
+	}
+	if _, err := RegisterStringVarWithOptions(empty, "a-default", "", Options{Required: true}); err != nil {
+		t.Fatalf("RegisterStringVarWithOptions(%s): %v", empty, err)
+	}
+
+	SetSource(fakeSource{empty: ""})
+
+	errs := ValidateAll()
+
+	if got := errsFor(errs, noDefault); len(got) != 1 {
+		t.Errorf("Required var unset with no default: got %d errors, want 1 (%v)", len(got), got)
+	}
+	if got := errsFor(errs, withDefault); len(got) != 0 {
+		t.Errorf("Required var unset with a default: got %d errors, want 0 (%v)", len(got), got)
+	}
+	if got := errsFor(errs, empty); len(got) != 1 {
+		t.Errorf("Required var explicitly set empty: got %d errors, want 1 (%v)", len(got), got)
+	}
+}
+
+func TestValidateAllAggregatesMultipleViolations(t *testing.T) {
+	prev := currentSource()
+	defer SetSource(prev)
+
+	const (
+		badChoice  = "TEST_VALIDATION_AGG_CHOICE"
+		badPattern = "TEST_VALIDATION_AGG_PATTERN"
+		clean      = "TEST_VALIDATION_AGG_CLEAN"
+	)
+
+	if _, err := RegisterStringVarWithOptions(badChoice, "a", "", Options{Choices: []string{"a", "b"}}); err != nil {
+		t.Fatalf("RegisterStringVarWithOptions(%s): %v", badChoice, err)
+	}
+	if _, err := RegisterStringVarWithOptions(badPattern, "abc", "", Options{Pattern: `^[a-z]+$`}); err != nil {
+		t.Fatalf("RegisterStringVarWithOptions(%s): %v", badPattern, err)
+	}
+	if _, err := RegisterStringVarWithOptions(clean, "abc", "", Options{Pattern: `^[a-z]+$`}); err != nil {
+		t.Fatalf("RegisterStringVarWithOptions(%s): %v", clean, err)
+	}
+
+	SetSource(fakeSource{
+		badChoice:  "not-a-choice",
+		badPattern: "ABC123",
+		clean:      "stillclean",
+	})
+
+	errs := ValidateAll()
+
+	if got := errsFor(errs, badChoice); len(got) != 1 {
+		t.Errorf("Choices violation: got %d errors, want 1 (%v)", len(got), got)
+	}
+	if got := errsFor(errs, badPattern); len(got) != 1 {
+		t.Errorf("Pattern violation: got %d errors, want 1 (%v)", len(got), got)
+	}
+	if got := errsFor(errs, clean); len(got) != 0 {
+		t.Errorf("conforming value: got %d errors, want 0 (%v)", len(got), got)
+	}
+}
@@ -17,7 +17,6 @@
 package env
 
 import (
-	"os"
 	"sort"
 	"strconv"
 	"sync"
@@ -37,6 +36,23 @@ const (
 	DURATION
 )
 
+func (t VarType) String() string {
+	switch t {
+	case STRING:
+		return "string"
+	case BOOL:
+		return "bool"
+	case INT:
+		return "int"
+	case FLOAT:
+		return "float"
+	case DURATION:
+		return "duration"
+	default:
+		return "unknown"
+	}
+}
+
 // Var describes a single environment variable
 type Var struct {
 	// The name of the environment variable.
@@ -56,6 +72,32 @@ type Var struct {
 
 	// The type of the variable's value
 	Type VarType
+
+	// Required marks this variable as one ValidateAll rejects if it is
+	// neither set in the environment nor given a non-empty default, or if it
+	// is set to an empty value.
+	Required bool
+
+	// Choices, if non-empty, is the set of values this variable is allowed
+	// to take.
+	Choices []string
+
+	// Min is the optional inclusive lower bound for numeric variables.
+	Min *float64
+
+	// Max is the optional inclusive upper bound for numeric variables.
+	Max *float64
+
+	// Pattern is an optional regular expression that string variables must
+	// match.
+	Pattern string
+
+	// Sensitive marks this variable's value as secret. Its default is
+	// redacted from VarDescriptions output, and its value may additionally
+	// be supplied indirectly via a NAME_FILE variable naming a file to read
+	// it from (see resolveRaw), so it need never appear in the process
+	// environment itself.
+	Sensitive bool
 }
 
 // StringVar represents a single string environment variable.
@@ -86,11 +128,17 @@ type DurationVar struct {
 var allVars = make(map[string]Var)
 var mutex sync.Mutex
 
-// Returns a description of this process' environment variables, sorted by name.
+const redactedValue = "<redacted>"
+
+// Returns a description of this process' environment variables, sorted by
+// name. Sensitive variables have their default redacted.
 func VarDescriptions() []Var {
 	mutex.Lock()
 	sorted := make([]Var, 0, len(allVars))
 	for _, v := range allVars {
+		if v.Sensitive && v.DefaultValue != "" {
+			v.DefaultValue = redactedValue
+		}
 		sorted = append(sorted, v)
 	}
 	mutex.Unlock()
@@ -109,6 +157,17 @@ func RegisterStringVar(name string, defaultValue string, description string) Str
 	return StringVar{v}
 }
 
+// RegisterSensitiveStringVar registers a new string environment variable
+// whose value is treated as secret: its default is redacted from
+// VarDescriptions output, and it may be supplied indirectly via a
+// NAME_FILE variable naming a mounted secret file, so the value itself
+// never needs to appear in the process environment or /proc/<pid>/environ.
+func RegisterSensitiveStringVar(name string, defaultValue string, description string) StringVar {
+	v := Var{Name: name, DefaultValue: defaultValue, Description: description, Type: STRING, Sensitive: true}
+	RegisterVar(v)
+	return StringVar{v}
+}
+
 // RegisterBoolVar registers a new boolean environment variable.
 func RegisterBoolVar(name string, defaultValue bool, description string) BoolVar {
 	v := Var{Name: name, DefaultValue: strconv.FormatBool(defaultValue), Description: description, Type: BOOL}
@@ -162,12 +221,16 @@ func (v StringVar) Get() string {
 }
 
 func (v StringVar) Lookup() (string, bool) {
-	result, ok := os.LookupEnv(v.Name)
-	if !ok {
-		result = v.DefaultValue
-	}
+	return resolveRaw(v.Var)
+}
 
-	return result, ok
+// OnChange registers cb to be invoked with the new raw value whenever v's
+// value changes, as reported by the active Source. The default Source,
+// backed by os.LookupEnv, never reports changes; install a watchable Source
+// such as FileSource via SetSource to observe operator-driven updates
+// without a pod restart.
+func (v StringVar) OnChange(cb func(newVal string)) {
+	registerOnChange(v.Var, cb)
 }
 
 func (v BoolVar) Get() bool {
@@ -176,18 +239,17 @@ func (v BoolVar) Get() bool {
 }
 
 func (v BoolVar) Lookup() (bool, bool) {
-	result, ok := os.LookupEnv(v.Name)
-	if !ok {
-		result = v.DefaultValue
-	}
-
-	b, err := strconv.ParseBool(result)
-	if err != nil {
-		log.Warnf("Invalid environment variable value `%s`, expecting true/false, defaulting to %v", result, v.DefaultValue)
-		b, _ = strconv.ParseBool(v.DefaultValue)
-	}
+	result, ok := resolveRaw(v.Var)
+	return parseBool(result, v.DefaultValue), ok
+}
 
-	return b, ok
+// OnChange registers cb to be invoked with the new parsed value whenever v's
+// value changes. See StringVar.OnChange for details on how changes are
+// reported.
+func (v BoolVar) OnChange(cb func(newVal bool)) {
+	registerOnChange(v.Var, func(raw string) {
+		cb(parseBool(raw, v.DefaultValue))
+	})
 }
 
 func (v IntVar) Get() int {
@@ -196,18 +258,17 @@ func (v IntVar) Get() int {
 }
 
 func (v IntVar) Lookup() (int, bool) {
-	result, ok := os.LookupEnv(v.Name)
-	if !ok {
-		result = v.DefaultValue
-	}
-
-	i, err := strconv.Atoi(result)
-	if err != nil {
-		log.Warnf("Invalid environment variable value `%s`, expecting an integer, defaulting to %v", result, v.DefaultValue)
-		i, _ = strconv.Atoi(v.DefaultValue)
-	}
+	result, ok := resolveRaw(v.Var)
+	return parseInt(result, v.DefaultValue), ok
+}
 
-	return i, ok
+// OnChange registers cb to be invoked with the new parsed value whenever v's
+// value changes. See StringVar.OnChange for details on how changes are
+// reported.
+func (v IntVar) OnChange(cb func(newVal int)) {
+	registerOnChange(v.Var, func(raw string) {
+		cb(parseInt(raw, v.DefaultValue))
+	})
 }
 
 func (v FloatVar) Get() float64 {
@@ -216,18 +277,17 @@ func (v FloatVar) Get() float64 {
 }
 
 func (v FloatVar) Lookup() (float64, bool) {
-	result, ok := os.LookupEnv(v.Name)
-	if !ok {
-		result = v.DefaultValue
-	}
-
-	f, err := strconv.ParseFloat(result, 64)
-	if err != nil {
-		log.Warnf("Invalid environment variable value `%s`, expecting a floating-point value, defaulting to %v", result, v.DefaultValue)
-		f, _ = strconv.ParseFloat(v.DefaultValue, 64)
-	}
+	result, ok := resolveRaw(v.Var)
+	return parseFloat(result, v.DefaultValue), ok
+}
 
-	return f, ok
+// OnChange registers cb to be invoked with the new parsed value whenever v's
+// value changes. See StringVar.OnChange for details on how changes are
+// reported.
+func (v FloatVar) OnChange(cb func(newVal float64)) {
+	registerOnChange(v.Var, func(raw string) {
+		cb(parseFloat(raw, v.DefaultValue))
+	})
 }
 
 func (v DurationVar) Get() time.Duration {
@@ -236,16 +296,51 @@ func (v DurationVar) Get() time.Duration {
 }
 
 func (v DurationVar) Lookup() (time.Duration, bool) {
-	result, ok := os.LookupEnv(v.Name)
-	if !ok {
-		result = v.DefaultValue
+	result, ok := resolveRaw(v.Var)
+	return parseDuration(result, v.DefaultValue), ok
+}
+
+// OnChange registers cb to be invoked with the new parsed value whenever v's
+// value changes. See StringVar.OnChange for details on how changes are
+// reported.
+func (v DurationVar) OnChange(cb func(newVal time.Duration)) {
+	registerOnChange(v.Var, func(raw string) {
+		cb(parseDuration(raw, v.DefaultValue))
+	})
+}
+
+func parseBool(result, defaultValue string) bool {
+	b, err := strconv.ParseBool(result)
+	if err != nil {
+		log.Warnf("Invalid environment variable value `%s`, expecting true/false, defaulting to %v", result, defaultValue)
+		b, _ = strconv.ParseBool(defaultValue)
 	}
+	return b
+}
 
-	d, err := time.ParseDuration(result)
+func parseInt(result, defaultValue string) int {
+	i, err := strconv.Atoi(result)
+	if err != nil {
+		log.Warnf("Invalid environment variable value `%s`, expecting an integer, defaulting to %v", result, defaultValue)
+		i, _ = strconv.Atoi(defaultValue)
+	}
+	return i
+}
+
+func parseFloat(result, defaultValue string) float64 {
+	f, err := strconv.ParseFloat(result, 64)
 	if err != nil {
-		log.Warnf("Invalid environment variable value `%s`, expecting a duration, defaulting to %v", result, v.DefaultValue)
-		d, _ = time.ParseDuration(v.DefaultValue)
+		log.Warnf("Invalid environment variable value `%s`, expecting a floating-point value, defaulting to %v", result, defaultValue)
+		f, _ = strconv.ParseFloat(defaultValue, 64)
 	}
+	return f
+}
 
-	return d, ok
+func parseDuration(result, defaultValue string) time.Duration {
+	d, err := time.ParseDuration(result)
+	if err != nil {
+		log.Warnf("Invalid environment variable value `%s`, expecting a duration, defaulting to %v", result, defaultValue)
+		d, _ = time.ParseDuration(defaultValue)
+	}
+	return d
 }